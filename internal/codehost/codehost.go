@@ -4,6 +4,10 @@
 
 // Package codehost defines the interface implemented by a code hosting source,
 // along with support code for use by implementations.
+//
+// It is used by the go command's module support and, through the public
+// package golang.org/x/vgo/vcs, by other tools, which is why it lives
+// outside the go command's internal packages.
 package codehost
 
 import (
@@ -17,10 +21,11 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"cmd/go/internal/cfg"
-	"cmd/go/internal/str"
+	"golang.org/x/vgo/internal/filelock"
+	"golang.org/x/vgo/internal/str"
 )
 
 // Downloaded size limits.
@@ -30,6 +35,17 @@ const (
 	MaxZipFile = 500 << 20 // maximum size of downloaded zip file
 )
 
+// bytesFetched counts the bytes transferred by fetches
+// from remote repositories, as far as they can be measured.
+var bytesFetched int64
+
+// BytesFetched returns the number of bytes fetched from remote
+// repositories so far. Only Git fetches are currently measured,
+// by the growth of the local repository's object store.
+func BytesFetched() int64 {
+	return atomic.LoadInt64(&bytesFetched)
+}
+
 // A Repo represents a code hosting source.
 // Typical implementations include local version control repositories,
 // remote version control servers, and code hosting sites.
@@ -47,6 +63,12 @@ type Repo interface {
 	// whatever that means in the underlying implementation.
 	Latest() (*RevInfo, error)
 
+	// StatAtTime returns information about the last revision
+	// committed at or before t on branch, or on the default branch
+	// if branch is empty, following only the branch's own history
+	// rather than the history of branches merged into it.
+	StatAtTime(branch string, t time.Time) (*RevInfo, error)
+
 	// ReadFile reads the given file in the file tree corresponding to revision rev.
 	// It should refuse to read more than maxSize bytes.
 	//
@@ -65,11 +87,18 @@ type Repo interface {
 }
 
 // A Rev describes a single revision in a source code repository.
+//
+// If the revision was named by an annotated tag, TagTime and TagMessage
+// describe the tag itself. Time is always the commit time, even then,
+// so that pseudo-versions depend only on the commit.
 type RevInfo struct {
 	Name    string    // complete ID in underlying repository
 	Short   string    // shortened ID, for use in pseudo-version
 	Version string    // TODO what is this?
 	Time    time.Time // commit time
+
+	TagTime    time.Time // time the annotated tag was made
+	TagMessage string    // annotated tag's message
 }
 
 // AllHex reports whether the revision rev is entirely lower-case hexadecimal digits.
@@ -97,6 +126,17 @@ func ShortenSHA1(rev string) string {
 // It is set by cmd/go/internal/vgo.InitMod.
 var WorkRoot string
 
+// Trace, if set, causes the commands run and the work directories
+// created to be printed to standard error, as with the go command's
+// -x flag, from which cmd/go/internal/vgo.InitMod sets it.
+var Trace bool
+
+// RateLimit, if not nil, is called with the host of a remote repository
+// before each command run that contacts the repository, and returns when
+// the rate limit for the host allows the command. The go command sets it
+// so that these commands share the limits of its HTTP requests.
+var RateLimit func(host string)
+
 // WorkDir returns the name of the cached work directory to use for the
 // given repository type and name.
 func WorkDir(typ, name string) (string, error) {
@@ -114,6 +154,15 @@ func WorkDir(typ, name string) (string, error) {
 	}
 	key := typ + ":" + name
 	dir := filepath.Join(WorkRoot, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+	if err := os.MkdirAll(WorkRoot, 0777); err != nil {
+		return "", err
+	}
+	unlock, err := LockDir(dir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	data, err := ioutil.ReadFile(dir + ".info")
 	info, err2 := os.Stat(dir)
 	if err == nil && err2 == nil && info.IsDir() {
@@ -122,14 +171,14 @@ func WorkDir(typ, name string) (string, error) {
 		if have != key {
 			return "", fmt.Errorf("%s exists with wrong content (have %q want %q)", dir+".info", have, key)
 		}
-		if cfg.BuildX {
+		if Trace {
 			fmt.Fprintf(os.Stderr, "# %s for %s %s\n", dir, typ, name)
 		}
 		return dir, nil
 	}
 
 	// Info file or directory missing. Start from scratch.
-	if cfg.BuildX {
+	if Trace {
 		fmt.Fprintf(os.Stderr, "mkdir -p %s # %s %s\n", dir, typ, name)
 	}
 	os.RemoveAll(dir)
@@ -160,6 +209,13 @@ func (e *RunError) Error() string {
 
 var dirLock sync.Map
 
+// LockDir acquires the lock for the work directory dir,
+// which serializes multi-step updates of dir among concurrent
+// go command processes. It returns a function that releases the lock.
+func LockDir(dir string) (unlock func(), err error) {
+	return filelock.Lock(dir + ".lock")
+}
+
 // Run runs the command line in the given directory
 // (an empty dir means the current directory).
 // It returns the standard output and, for a non-zero exit,
@@ -177,7 +233,12 @@ func Run(dir string, cmdline ...interface{}) ([]byte, error) {
 	}
 
 	cmd := str.StringList(cmdline...)
-	if cfg.BuildX {
+	if RateLimit != nil && isRemoteCommand(cmd) {
+		if u := dirRemoteURL(dir); u != nil {
+			RateLimit(u.Hostname())
+		}
+	}
+	if Trace {
 		var text string
 		if dir != "" {
 			text = "cd " + dir + "; "
@@ -195,6 +256,7 @@ func Run(dir string, cmdline ...interface{}) ([]byte, error) {
 	var stdout bytes.Buffer
 	c := exec.Command(cmd[0], cmd[1:]...)
 	c.Dir = dir
+	c.Env = runEnv(dir)
 	c.Stderr = &stderr
 	c.Stdout = &stdout
 	err := c.Run()
@@ -203,3 +265,30 @@ func Run(dir string, cmdline ...interface{}) ([]byte, error) {
 	}
 	return stdout.Bytes(), err
 }
+
+// remoteCommands lists, for each version control tool, the subcommands
+// that contact the remote repository. Every svn command contacts it,
+// since svn keeps no local copy.
+var remoteCommands = map[string]map[string]bool{
+	"git":    {"clone": true, "fetch": true, "ls-remote": true},
+	"hg":     {"clone": true, "pull": true},
+	"bzr":    {"branch": true, "pull": true},
+	"fossil": {"clone": true, "pull": true},
+}
+
+// isRemoteCommand reports whether the command line cmd
+// contacts the remote repository.
+func isRemoteCommand(cmd []string) bool {
+	if cmd[0] == "svn" {
+		return true
+	}
+	for i := 1; i < len(cmd); i++ {
+		switch arg := cmd[i]; {
+		case arg == "-c":
+			i++ // skip git's -c name=value
+		case !strings.HasPrefix(arg, "-"):
+			return remoteCommands[cmd[0]][arg]
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codehost
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsRemoteCommand(t *testing.T) {
+	for _, tt := range []struct {
+		cmd    string
+		remote bool
+	}{
+		{"git fetch -f origin refs/tags/*:refs/tags/*", true},
+		{"git -c protocol.version=2 ls-remote -q --tags https://example.com/r", true},
+		{"git -c protocol.version=2 fetch --depth=1 origin", true},
+		{"git -c fetch.x=y tag -l", false},
+		{"git cat-file blob abc:go.mod", false},
+		{"hg pull -f", true},
+		{"hg log -l1 -r tip", false},
+		{"svn cat https://example.com/r/go.mod@1", true},
+		{"bzr branch --use-existing-dir https://example.com/r .", true},
+		{"bzr tags", false},
+		{"fossil pull -R .fossil", true},
+		{"fossil info -R .fossil tip", false},
+		{"git", false},
+	} {
+		if remote := isRemoteCommand(strings.Fields(tt.cmd)); remote != tt.remote {
+			t.Errorf("isRemoteCommand(%q) = %v, want %v", tt.cmd, remote, tt.remote)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codehost
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/vgo/internal/par"
+)
+
+// GitRepo returns the code repository at the given Git remote reference.
+func GitRepo(remote string) (Repo, error) {
+	return newGitRepoCached(remote, false)
+}
+
+// LocalGitRepo is like Repo but accepts both Git remote references
+// and paths to repositories on the local file system.
+func LocalGitRepo(remote string) (Repo, error) {
+	return newGitRepoCached(remote, true)
+}
+
+const gitWorkDirType = "git2"
+
+var gitRepoCache par.Cache
+
+func newGitRepoCached(remote string, localOK bool) (Repo, error) {
+	type key struct {
+		remote  string
+		localOK bool
+	}
+	type cached struct {
+		repo Repo
+		err  error
+	}
+
+	c := gitRepoCache.Do(key{remote, localOK}, func() interface{} {
+		repo, err := newGitRepo(remote, localOK)
+		return cached{repo, err}
+	}).(cached)
+
+	return c.repo, c.err
+}
+
+func newGitRepo(remote string, localOK bool) (Repo, error) {
+	r := &gitRepo{remote: remote}
+	if strings.Contains(remote, "://") {
+		// This is a remote path.
+		dir, err := WorkDir(gitWorkDirType, r.remote)
+		if err != nil {
+			return nil, err
+		}
+		r.dir = dir
+		setDirRemote(dir, remote)
+		unlock, err := LockDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if _, err := os.Stat(filepath.Join(dir, "objects")); err != nil {
+			if _, err := Run(dir, "git", "init", "--bare"); err != nil {
+				os.RemoveAll(dir)
+				return nil, err
+			}
+			// We could just say git fetch https://whatever later,
+			// but this lets us say git fetch origin instead, which
+			// is a little nicer. More importantly, using a named remote
+			// avoids a problem with Git LFS. See golang.org/issue/25605.
+			if _, err := Run(dir, "git", "remote", "add", "origin", r.remote); err != nil {
+				os.RemoveAll(dir)
+				return nil, err
+			}
+			r.remote = "origin"
+		}
+		if partialClone {
+			if err := r.enablePartialClone(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// Local path.
+		// Disallow colon (not in ://) because sometimes
+		// that's rcp-style host:path syntax and sometimes it's not (c:\work).
+		// The go command has always insisted on URL syntax for ssh.
+		if strings.Contains(remote, ":") {
+			return nil, fmt.Errorf("git remote cannot use host:path syntax")
+		}
+		if !localOK {
+			return nil, fmt.Errorf("git remote must not be local directory")
+		}
+		r.local = true
+		info, err := os.Stat(remote)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s exists but is not a directory", remote)
+		}
+		r.dir = remote
+	}
+	return r, nil
+}
+
+// partialClone reports whether to use Git partial clones,
+// set by $GOMODPARTIALCLONE=1. A partial clone fetches commits
+// and trees but leaves file contents (blobs) to be fetched on demand,
+// so that creating the zip file for a module in a small subdirectory
+// of an enormous repository only downloads that subdirectory's files.
+// The Git server must support partial clone filters;
+// servers that do not will send complete fetches instead.
+var partialClone = os.Getenv("GOMODPARTIALCLONE") == "1"
+
+type gitRepo struct {
+	remote  string
+	local   bool
+	dir     string
+	partial bool // partial clone; see partialClone
+
+	mu         sync.Mutex // protects fetchLevel, some git repo state
+	fetchLevel int
+
+	statCache par.Cache
+
+	refsOnce sync.Once
+	refs     map[string]string
+	refsErr  error
+
+	tagsOnce sync.Once
+	tags     map[string]string // like refs, but only tags
+	tagsErr  error
+
+	localTagsOnce sync.Once
+	localTags     map[string]bool
+}
+
+const (
+	// How much have we fetched into the git repo (in this process)?
+	fetchNone = iota // nothing yet
+	fetchSome        // shallow fetches of individual hashes
+	fetchAll         // "fetch -t origin": get all remote branches and tags
+)
+
+// loadLocalTags loads tag references from the local git cache
+// into the map r.localTags.
+// Should only be called as r.localTagsOnce.Do(r.loadLocalTags).
+func (r *gitRepo) loadLocalTags() {
+	// The git protocol sends all known refs and ls-remote filters them on the client side,
+	// so we might as well record both heads and tags in one shot.
+	// Most of the time we only care about tags but sometimes we care about heads too.
+	out, err := Run(r.dir, "git", "tag", "-l")
+	if err != nil {
+		return
+	}
+
+	r.localTags = make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			r.localTags[line] = true
+		}
+	}
+}
+
+// loadRefs loads heads and tags references from the remote into the map r.refs.
+// Should only be called as r.refsOnce.Do(r.loadRefs).
+func (r *gitRepo) loadRefs() {
+	// The git protocol sends all known refs and ls-remote filters them on the client side,
+	// so we might as well record both heads and tags in one shot.
+	// Most of the time we only care about tags but sometimes we care about heads too.
+	out, err := r.lsRemote("refs", "git", "ls-remote", "-q", r.remote)
+	if err != nil {
+		r.refsErr = err
+		return
+	}
+
+	r.refs = parseRefs(out)
+}
+
+// RefsTTL is how long listings of a remote repository's references
+// are cached on disk. The default is one minute; 0 disables the cache.
+// Package vgo sets it from $GOMODREFTTL (as in "30s" or "5m").
+//
+// Within a process, all modules in a repository already share
+// a single gitRepo and so a single listing. The disk cache lets
+// a quick succession of vgo commands share one as well.
+var RefsTTL = time.Minute
+
+// lsRemote runs the git ls-remote command line cmdline,
+// returning the output cached on disk under the given name
+// if the cached copy is younger than RefsTTL.
+func (r *gitRepo) lsRemote(name string, cmdline ...interface{}) ([]byte, error) {
+	if r.local || RefsTTL <= 0 {
+		return Run(r.dir, cmdline...)
+	}
+	file := r.dir + "." + name
+	if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) < RefsTTL {
+		if data, err := ioutil.ReadFile(file); err == nil {
+			return data, nil
+		}
+	}
+	out, err := Run(r.dir, cmdline...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write to a temporary file and rename,
+	// so that readers never see a partial listing.
+	if f, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp-"); err == nil {
+		_, err := f.Write(out)
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), file)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}
+	return out, nil
+}
+
+// parseRefs parses the output of git ls-remote,
+// returning a map from the HEAD, heads, and tags references
+// listed to their commit hashes.
+func parseRefs(out []byte) map[string]string {
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		if f[1] == "HEAD" || strings.HasPrefix(f[1], "refs/heads/") || strings.HasPrefix(f[1], "refs/tags/") {
+			refs[f[1]] = f[0]
+		}
+	}
+	for ref, hash := range refs {
+		if strings.HasSuffix(ref, "^{}") { // record unwrapped annotated tag as value of tag
+			refs[strings.TrimSuffix(ref, "^{}")] = hash
+			delete(refs, ref)
+		}
+	}
+	return refs
+}
+
+// loadTags loads the tag references from the remote into the map r.tags.
+// Should only be called as r.tagsOnce.Do(r.loadTags).
+//
+// Listing versions only needs the tags, and some servers (Gerrit mirrors,
+// for example) have hundreds of thousands of other references.
+// Git protocol version 2 lets ls-remote --tags ask the server to send
+// only the references beginning with refs/tags/, instead of all of them.
+// Older versions of Git that do not understand protocol version 2
+// fail the command, in which case loadTags falls back to the full list.
+func (r *gitRepo) loadTags() {
+	out, err := r.lsRemote("tags", "git", "-c", "protocol.version=2", "ls-remote", "-q", "--tags", r.remote)
+	if err != nil {
+		r.refsOnce.Do(r.loadRefs)
+		r.tags, r.tagsErr = r.refs, r.refsErr
+		return
+	}
+	r.tags = parseRefs(out)
+}
+
+func (r *gitRepo) Tags(prefix string) ([]string, error) {
+	r.tagsOnce.Do(r.loadTags)
+	if r.tagsErr != nil {
+		return nil, r.tagsErr
+	}
+
+	tags := []string{}
+	for ref := range r.tags {
+		if !strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		tag := ref[len("refs/tags/"):]
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// Latest returns the latest revision on the default branch,
+// which is the branch the remote's HEAD points at, whatever its name.
+// If the remote does not report a HEAD but has only one branch,
+// that branch is the default.
+func (r *gitRepo) Latest() (*RevInfo, error) {
+	r.refsOnce.Do(r.loadRefs)
+	if r.refsErr != nil {
+		return nil, r.refsErr
+	}
+	if r.refs["HEAD"] != "" {
+		return r.Stat(r.refs["HEAD"])
+	}
+	var heads []string
+	for ref := range r.refs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			heads = append(heads, ref)
+		}
+	}
+	switch len(heads) {
+	case 0:
+		return nil, fmt.Errorf("no commits")
+	case 1:
+		return r.Stat(r.refs[heads[0]])
+	}
+	sort.Strings(heads)
+	return nil, fmt.Errorf("no default branch: remote does not report HEAD and has branches %s", strings.Join(heads, ", "))
+}
+
+// findRef finds some ref name for the given hash,
+// for use when the server requires giving a ref instead of a hash.
+// There may be multiple ref names for a given hash,
+// in which case this returns some name - it doesn't matter which.
+func (r *gitRepo) findRef(hash string) (ref string, ok bool) {
+	r.refsOnce.Do(r.loadRefs)
+	for ref, h := range r.refs {
+		if h == hash {
+			return ref, true
+		}
+	}
+	return "", false
+}
+
+func unshallow(gitDir string) []string {
+	if _, err := os.Stat(filepath.Join(gitDir, "shallow")); err == nil {
+		return []string{"--unshallow"}
+	}
+	return []string{}
+}
+
+// minHashDigits is the minimum number of digits to require
+// before accepting a hex digit sequence as potentially identifying
+// a specific commit in a git repo. (Of course, users can always
+// specify more digits, and many will paste in all 40 digits,
+// but many of git's commands default to printing short hashes
+// as 7 digits.)
+const minHashDigits = 7
+
+// stat stats the given rev in the local repository,
+// or else it fetches more info from the remote repository and tries again.
+func (r *gitRepo) stat(rev string) (*RevInfo, error) {
+	if r.local {
+		return r.statLocal(rev, rev)
+	}
+
+	// Fast path: maybe rev is a hash we already have locally.
+	if len(rev) >= minHashDigits && len(rev) <= 40 && AllHex(rev) {
+		if info, err := r.statLocal(rev, rev); err == nil {
+			return info, nil
+		}
+	}
+
+	// Maybe rev is a tag we already have locally.
+	// (Note that we're excluding branches, which can be stale.)
+	r.localTagsOnce.Do(r.loadLocalTags)
+	if r.localTags[rev] {
+		return r.statLocal(rev, "refs/tags/"+rev)
+	}
+
+	// Maybe rev is the name of a tag or branch on the remote server.
+	// Or maybe it's the prefix of a hash of a named ref.
+	// Try to resolve to both a ref (git name) and full (40-hex-digit) commit hash.
+	r.refsOnce.Do(r.loadRefs)
+	var ref, hash string
+	if r.refs["refs/tags/"+rev] != "" {
+		ref = "refs/tags/" + rev
+		hash = r.refs[ref]
+		// Keep rev as is: tags are assumed not to change meaning.
+	} else if r.refs["refs/heads/"+rev] != "" {
+		ref = "refs/heads/" + rev
+		hash = r.refs[ref]
+		rev = hash // Replace rev, because meaning of refs/heads/foo can change.
+	} else if rev == "HEAD" && r.refs["HEAD"] != "" {
+		ref = "HEAD"
+		hash = r.refs[ref]
+		rev = hash // Replace rev, because meaning of HEAD can change.
+	} else if len(rev) >= minHashDigits && len(rev) <= 40 && AllHex(rev) {
+		// At the least, we have a hash prefix we can look up after the fetch below.
+		// Maybe we can map it to a full hash using the known refs.
+		prefix := rev
+		// Check whether rev is prefix of known ref hash.
+		for k, h := range r.refs {
+			if strings.HasPrefix(h, prefix) {
+				if hash != "" && hash != h {
+					// Hash is an ambiguous hash prefix.
+					// More information will not change that.
+					return nil, fmt.Errorf("ambiguous revision %s", rev)
+				}
+				if ref == "" || ref > k { // Break ties deterministically when multiple refs point at same hash.
+					ref = k
+				}
+				rev = h
+				hash = h
+			}
+		}
+		if hash == "" && len(rev) == 40 { // Didn't find a ref, but rev is a full hash.
+			hash = rev
+		}
+	} else if strings.HasPrefix(rev, "refs/") {
+		// A full ref name outside heads and tags, such as
+		// the refs/pull/123/head of a GitHub pull request.
+		// ls-remote does not tell us about these; fetch it directly below.
+		ref = rev
+	} else {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+
+	// Protect r.fetchLevel and the "fetch more and more" sequence,
+	// both within this process and against other processes.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.local {
+		unlock, err := LockDir(r.dir)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	// If we only know a ref name, fetch that ref.
+	// Its meaning can change (a pull request can be updated),
+	// so report the commit hash as the version, not the ref.
+	if hash == "" && ref != "" {
+		var depth []string
+		if r.fetchLevel < fetchAll {
+			r.fetchLevel = fetchSome
+			depth = []string{"--depth=1"}
+		}
+		if _, err := r.fetch(nil, "-f", depth, r.remote, ref+":"+ref); err != nil {
+			return nil, err
+		}
+		return r.statLocal("", ref)
+	}
+
+	// If we know a specific commit we need, fetch it.
+	if r.fetchLevel <= fetchSome && hash != "" {
+		r.fetchLevel = fetchSome
+		var refspec string
+		if ref != "" {
+			// If we do know the ref name, save the mapping locally
+			// so that (if it is a tag) it can show up in localTags
+			// on a future call. Also, some servers refuse to allow
+			// full hashes in ref specs, so prefer a ref name if known.
+			refspec = ref + ":" + ref
+		} else {
+			ref = hash
+			refspec = hash
+		}
+		_, err := r.fetch(nil, "-f", "--depth=1", r.remote, refspec)
+		if err == nil {
+			return r.statLocal(rev, ref)
+		}
+		if !strings.Contains(err.Error(), "unadvertised object") && !strings.Contains(err.Error(), "no such remote ref") && !strings.Contains(err.Error(), "does not support shallow") {
+			return nil, err
+		}
+	}
+
+	// Last resort.
+	// Fetch all heads and tags and hope the hash we want is in the history.
+	if err := r.fetchAll(); err != nil {
+		return nil, err
+	}
+
+	return r.statLocal(rev, rev)
+}
+
+// fetchAll fetches all heads and tags, with their complete history,
+// unless that has already been done.
+// The caller must hold r.mu and the lock on r.dir.
+func (r *gitRepo) fetchAll() error {
+	if r.fetchLevel >= fetchAll {
+		return nil
+	}
+	r.fetchLevel = fetchAll
+
+	// To work around a protocol version 2 bug that breaks --unshallow,
+	// add -c protocol.version=0.
+	// TODO(rsc): The bug is believed to be server-side, meaning only
+	// on Google's Git servers. Once the servers are fixed, drop the
+	// protocol.version=0. See Google-internal bug b/110495752.
+	var protoFlag []string
+	unshallowFlag := unshallow(r.dir)
+	if len(unshallowFlag) > 0 {
+		protoFlag = []string{"-c", "protocol.version=0"}
+	}
+	_, err := r.fetch(protoFlag, unshallowFlag, "-f", r.remote, "refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*")
+	return err
+}
+
+// StatAtTime returns the last commit at or before t in the
+// first-parent history of branch, or of the default branch if
+// branch is empty. Commits are dated by their commit time,
+// the same time that pseudo-versions record.
+func (r *gitRepo) StatAtTime(branch string, t time.Time) (*RevInfo, error) {
+	var tip *RevInfo
+	var err error
+	if branch == "" {
+		tip, err = r.Latest()
+	} else {
+		tip, err = r.Stat(branch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Earlier fetches may have been shallow; the search needs the full history.
+	if !r.local {
+		err := func() error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			unlock, err := LockDir(r.dir)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return r.fetchAll()
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := Run(r.dir, "git", "rev-list", "-n1", "--first-parent", "--before=@"+strconv.FormatInt(t.Unix(), 10), tip.Name)
+	if err != nil {
+		return nil, err
+	}
+	hash := strings.TrimSpace(string(out))
+	if hash == "" {
+		return nil, fmt.Errorf("no commits at or before %s", t.UTC().Format(time.RFC3339))
+	}
+	return r.Stat(hash)
+}
+
+// fetch runs "git gitFlags fetch args" in the local repository,
+// adding the size of the objects it transfers to BytesFetched.
+// In a partial clone, fetch leaves out file contents.
+func (r *gitRepo) fetch(gitFlags []string, args ...interface{}) ([]byte, error) {
+	var filter []string
+	if r.partial {
+		filter = []string{"--filter=blob:none"}
+	}
+	before := objectSize(r.dir)
+	out, err := Run(r.dir, append([]interface{}{"git", gitFlags, "fetch", filter}, args...)...)
+	if n := objectSize(r.dir) - before; n > 0 {
+		atomic.AddInt64(&bytesFetched, n)
+	}
+	return out, err
+}
+
+// objectSize returns the disk space used by the objects
+// in the Git repository dir, loose and packed, in bytes.
+func objectSize(dir string) int64 {
+	out, err := Run(dir, "git", "count-objects", "-v")
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && (f[0] == "size:" || f[0] == "size-pack:") {
+			kb, _ := strconv.ParseInt(f[1], 10, 64)
+			n += kb << 10
+		}
+	}
+	return n
+}
+
+// statLocal returns a RevInfo describing rev in the local git repository.
+// It uses version as info.Version.
+func (r *gitRepo) statLocal(version, rev string) (*RevInfo, error) {
+	out, err := Run(r.dir, "git", "log", "-n1", "--format=format:%H %ct", rev)
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	f := strings.Fields(string(out))
+	if len(f) != 2 {
+		return nil, fmt.Errorf("unexpected response from git log: %q", out)
+	}
+	hash := f[0]
+	if strings.HasPrefix(hash, version) {
+		version = hash // extend to full hash
+	}
+	t, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time from git log: %q", out)
+	}
+
+	info := &RevInfo{
+		Name:    hash,
+		Short:   ShortenSHA1(hash),
+		Time:    time.Unix(t, 0).UTC(),
+		Version: version,
+	}
+	if tag := r.tagRef(rev); tag != "" {
+		r.statTag(info, tag)
+	}
+	return info, nil
+}
+
+// tagRef returns the full name of the local tag that rev names,
+// or "" if rev does not name a tag.
+func (r *gitRepo) tagRef(rev string) string {
+	if strings.HasPrefix(rev, "refs/tags/") {
+		return rev
+	}
+	r.localTagsOnce.Do(r.loadLocalTags)
+	if r.localTags[rev] {
+		return "refs/tags/" + rev
+	}
+	return ""
+}
+
+// statTag fills in info.TagTime and info.TagMessage if tag,
+// a full reference name, is an annotated tag.
+// If tag points at another annotated tag, as happens when a
+// tag is retagged, these describe the outer tag, while the rest
+// of info describes the commit at the end of the chain.
+func (r *gitRepo) statTag(info *RevInfo, tag string) {
+	out, err := Run(r.dir, "git", "for-each-ref", "--format=%(objecttype) %(taggerdate:raw)%0a%(contents:subject)%0a%0a%(contents:body)", tag)
+	if err != nil {
+		return
+	}
+	i := strings.Index(string(out), "\n")
+	if i < 0 {
+		return
+	}
+	f := strings.Fields(string(out[:i]))
+	if len(f) < 2 || f[0] != "tag" {
+		return // lightweight tag
+	}
+	t, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return
+	}
+	info.TagTime = time.Unix(t, 0).UTC()
+	info.TagMessage = strings.TrimSpace(string(out[i+1:]))
+}
+
+func (r *gitRepo) Stat(rev string) (*RevInfo, error) {
+	if rev == "latest" {
+		return r.Latest()
+	}
+	type cached struct {
+		info *RevInfo
+		err  error
+	}
+	c := r.statCache.Do(rev, func() interface{} {
+		info, err := r.stat(rev)
+		return cached{info, err}
+	}).(cached)
+	return c.info, c.err
+}
+
+func (r *gitRepo) ReadFile(rev, file string, maxSize int64) ([]byte, error) {
+	// TODO: Could use git cat-file --batch.
+	info, err := r.Stat(rev) // download rev into local git repo
+	if err != nil {
+		return nil, err
+	}
+	out, err := Run(r.dir, "git", "cat-file", "blob", info.Name+":"+file)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	return out, nil
+}
+
+func (r *gitRepo) ReadZip(rev, subdir string, maxSize int64) (zip io.ReadCloser, actualSubdir string, err error) {
+	// TODO: Use maxSize or drop it.
+	args := []string{}
+	if subdir != "" {
+		args = append(args, "--", subdir)
+	}
+	info, err := r.Stat(rev) // download rev into local git repo
+	if err != nil {
+		return nil, "", err
+	}
+	treeish, prefix := info.Name, "prefix/"
+	if r.partial {
+		// Fetch the file contents in one batch, instead of letting
+		// git archive fetch them one at a time.
+		if err := r.fetchBlobs(info.Name, subdir); err != nil {
+			return nil, "", err
+		}
+		// Archive the subdirectory's tree directly: given a pathspec,
+		// git archive reads (and so fetches) files outside it too.
+		if subdir != "" {
+			treeish, prefix, args = info.Name+":"+subdir, prefix+subdir+"/", nil
+		}
+	}
+	archive, err := Run(r.dir, "git", "archive", "--format=zip", "--prefix="+prefix, treeish, args)
+	if err != nil {
+		if stderr := err.(*RunError).Stderr; bytes.Contains(stderr, []byte("did not match any files")) || bytes.Contains(stderr, []byte("not a valid object name")) {
+			return nil, "", os.ErrNotExist
+		}
+		return nil, "", err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(archive)), "", nil
+}
+
+// enablePartialClone configures the local repository
+// as a partial clone of origin.
+func (r *gitRepo) enablePartialClone() error {
+	for _, kv := range [][2]string{
+		{"core.repositoryformatversion", "1"},
+		{"extensions.partialClone", "origin"},
+		{"remote.origin.promisor", "true"},
+		{"remote.origin.partialclonefilter", "blob:none"},
+	} {
+		if _, err := Run(r.dir, "git", "config", kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	r.partial = true
+	return nil
+}
+
+// fetchBlobs fetches the file contents in the subdir subdirectory
+// (or the whole tree, if subdir is empty) of the commit hash
+// into a partial clone.
+func (r *gitRepo) fetchBlobs(hash, subdir string) error {
+	var args []string
+	if subdir != "" {
+		args = []string{"--", subdir}
+	}
+	out, err := Run(r.dir, "git", "ls-tree", "-r", "--full-tree", hash, args)
+	if err != nil {
+		return err
+	}
+	var blobs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		// <mode> SP <type> SP <object> TAB <file>
+		f := strings.Fields(line)
+		if len(f) >= 3 && f[1] == "blob" {
+			blobs = append(blobs, f[2])
+		}
+	}
+
+	// Fetch in batches to keep command lines reasonably short.
+	const batch = 500
+	for len(blobs) > 0 {
+		n := len(blobs)
+		if n > batch {
+			n = batch
+		}
+		if _, err := r.fetch([]string{"-c", "fetch.negotiationAlgorithm=noop"}, "--no-filter", "--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", r.remote, blobs[:n]); err != nil {
+			return err
+		}
+		blobs = blobs[n:]
+	}
+	return nil
+}
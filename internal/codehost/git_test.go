@@ -7,8 +7,8 @@ package codehost
 import (
 	"archive/zip"
 	"bytes"
+	"flag"
 	"fmt"
-	"internal/testenv"
 	"io/ioutil"
 	"log"
 	"os"
@@ -19,9 +19,14 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/vgo/internal/testenv"
 )
 
 func TestMain(m *testing.M) {
+	// testMain consults testenv.HasExternalNetwork,
+	// which needs the flags parsed.
+	flag.Parse()
 	os.Exit(testMain(m))
 }
 
@@ -581,9 +586,16 @@ func TestStat(t *testing.T) {
 				}
 				return
 			}
-			if *info != *tt.info {
+			// The tag time and message are checked separately,
+			// since only the Git repositories have annotated tags.
+			have := *info
+			have.TagTime, have.TagMessage = time.Time{}, ""
+			if have != *tt.info {
 				t.Errorf("Stat: incorrect info\nhave %+v\nwant %+v", *info, *tt.info)
 			}
+			if annotated := tt.repo != hgrepo1 && strings.HasSuffix(tt.rev, "-annotated"); annotated != !info.TagTime.IsZero() {
+				t.Errorf("Stat: TagTime = %v, want annotated=%v", info.TagTime, annotated)
+			}
 		}
 		t.Run(path.Base(tt.repo)+"/"+tt.rev, f)
 		if tt.repo == gitrepo1 {
@@ -621,3 +633,123 @@ func remap(name string, m map[string]string) string {
 	}
 	return name
 }
+
+// gitPullRepo creates a Git repository in a new directory under dir
+// with one commit on its branch and a second commit reachable only from
+// refs/pull/123/head, as GitHub publishes pull requests.
+// It returns the repository directory and the second commit's hash.
+func gitPullRepo(t *testing.T, dir string) (repo, hash string) {
+	repo = filepath.Join(dir, "pullrepo")
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=gopher", "GIT_AUTHOR_EMAIL=gopher@golang.org", "GIT_AUTHOR_DATE=2018-04-01T12:00:00Z",
+			"GIT_COMMITTER_NAME=gopher", "GIT_COMMITTER_EMAIL=gopher@golang.org", "GIT_COMMITTER_DATE=2018-04-01T12:00:00Z")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.MkdirAll(repo, 0777); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "initial")
+	git("checkout", "-q", "--detach")
+	git("commit", "-q", "--allow-empty", "-m", "proposed change")
+	hash = git("rev-parse", "HEAD")
+	git("update-ref", "refs/pull/123/head", hash)
+	git("checkout", "-q", "-")
+	return repo, hash
+}
+
+func TestStatPullRef(t *testing.T) {
+	testenv.MustHaveExec(t)
+
+	dir, err := ioutil.TempDir("", "gitrepo-pull-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo, hash := gitPullRepo(t, dir)
+
+	r, err := LocalGitRepo("file://" + filepath.ToSlash(repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := r.Stat("refs/pull/123/head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RevInfo{
+		Name:    hash,
+		Short:   ShortenSHA1(hash),
+		Time:    time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC),
+		Version: hash,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Stat(refs/pull/123/head) = %+v, want %+v", info, want)
+	}
+	if _, err := r.Stat("refs/pull/124/head"); err == nil {
+		t.Errorf("Stat(refs/pull/124/head): succeeded for missing ref")
+	}
+}
+
+func TestLsRemoteCache(t *testing.T) {
+	testenv.MustHaveExec(t)
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	defer func(ttl time.Duration) { RefsTTL = ttl }(RefsTTL)
+
+	dir, err := ioutil.TempDir("", "gitrepo-refs-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Stand in for git ls-remote with a command
+	// whose output the test controls.
+	r := &gitRepo{remote: "origin", dir: filepath.Join(dir, "repo")}
+	if err := os.Mkdir(r.dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	listing := filepath.Join(dir, "listing")
+	list := func(out string) {
+		t.Helper()
+		if err := ioutil.WriteFile(listing, []byte(out), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(want string) {
+		t.Helper()
+		out, err := r.lsRemote("refs", "cat", listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != want {
+			t.Errorf("lsRemote = %q, want %q", out, want)
+		}
+	}
+
+	RefsTTL = time.Hour
+	list("one")
+	check("one")
+	list("two")
+	check("one") // reused within the TTL
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(r.dir+".refs", old, old); err != nil {
+		t.Fatal(err)
+	}
+	check("two") // expired, so listed again
+	list("three")
+	check("two")
+
+	RefsTTL = 0
+	check("three") // no cache
+	list("four")
+	check("four")
+}
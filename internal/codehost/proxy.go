@@ -0,0 +1,105 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codehost
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The version control tools that Run starts read the outbound proxy
+// settings from the environment, but not as net/http does: git (through
+// libcurl) ignores $HTTP_PROXY and reads $NO_PROXY without CIDR ranges,
+// and hg reads only $http_proxy, for HTTPS too. So that the tools and
+// the go command's own HTTP requests agree on when to use which proxy,
+// Run decides for the tool, as net/http would for the tool's remote,
+// and passes it only the proxy variables expressing that decision.
+
+// dirRemote maps a work directory to the URL of the remote repository
+// that commands run in it contact. It is filled in by setDirRemote.
+var dirRemote sync.Map
+
+// setDirRemote records that commands run in the work directory dir
+// contact the remote repository at the URL remote.
+func setDirRemote(dir, remote string) {
+	dirRemote.Store(dir, remote)
+}
+
+// dirRemoteURL returns the URL of the remote repository that commands
+// run in the work directory dir contact, or nil if it is not known
+// or not a URL.
+func dirRemoteURL(dir string) *url.URL {
+	remote, ok := dirRemote.Load(dir)
+	if !ok {
+		return nil
+	}
+	u, err := url.Parse(remote.(string))
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// proxyVars lists the environment variables, in lower case,
+// that tools consult for the outbound proxy.
+var proxyVars = []string{"http_proxy", "https_proxy", "all_proxy", "no_proxy"}
+
+// directProxyVars lists the proxy variables, in lower case, that
+// proxyEnv removes when net/http uses no proxy for a remote. It leaves
+// $all_proxy, which net/http does not read, and so has not decided
+// against, and which may name a SOCKS proxy that only the tools use,
+// along with $no_proxy, which keeps such a proxy away from local hosts.
+var directProxyVars = []string{"http_proxy", "https_proxy"}
+
+// proxyFromEnvironment is http.ProxyFromEnvironment,
+// which reads the environment only once, replaced in tests.
+var proxyFromEnvironment = http.ProxyFromEnvironment
+
+// runEnv returns the environment for a command run in dir:
+// nil, to inherit the environment unchanged, unless dir is the work
+// directory of an HTTP or HTTPS remote, in which case it is the process
+// environment with the proxy variables replaced to name the proxy, if
+// any, that net/http uses for the remote (see proxyEnv).
+func runEnv(dir string) []string {
+	u := dirRemoteURL(dir)
+	if u == nil || u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	proxy, err := proxyFromEnvironment(&http.Request{URL: u})
+	if err != nil {
+		return nil
+	}
+	return proxyEnv(os.Environ(), proxy)
+}
+
+// proxyEnv returns env with the proxy variables replaced by ones naming
+// proxy or, if proxy is nil, with those in directProxyVars removed.
+func proxyEnv(env []string, proxy *url.URL) []string {
+	names := proxyVars
+	if proxy == nil {
+		names = directProxyVars
+	}
+	var out []string
+	for _, kv := range env {
+		isProxy := false
+		for _, name := range names {
+			if len(kv) > len(name) && kv[len(name)] == '=' && strings.EqualFold(kv[:len(name)], name) {
+				isProxy = true
+				break
+			}
+		}
+		if !isProxy {
+			out = append(out, kv)
+		}
+	}
+	if proxy != nil {
+		p := proxy.String()
+		out = append(out, "http_proxy="+p, "https_proxy="+p, "HTTPS_PROXY="+p)
+	}
+	return out
+}
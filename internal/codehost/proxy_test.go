@@ -0,0 +1,55 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codehost
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProxyEnv(t *testing.T) {
+	env := []string{"HOME=/home/gopher", "HTTPS_PROXY=http://old:3128", "http_proxy=http://old:3128", "ALL_PROXY=socks5://old:1080", "NO_PROXY=.corp", "no_proxy_x=1"}
+	if have, want := proxyEnv(env, nil), []string{"HOME=/home/gopher", "ALL_PROXY=socks5://old:1080", "NO_PROXY=.corp", "no_proxy_x=1"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("proxyEnv(env, nil) = %q, want %q", have, want)
+	}
+	proxy, _ := url.Parse("http://proxy.corp:3128")
+	want := []string{"HOME=/home/gopher", "no_proxy_x=1", "http_proxy=http://proxy.corp:3128", "https_proxy=http://proxy.corp:3128", "HTTPS_PROXY=http://proxy.corp:3128"}
+	if have := proxyEnv(env, proxy); !reflect.DeepEqual(have, want) {
+		t.Errorf("proxyEnv(env, %v) = %q, want %q", proxy, have, want)
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	defer func(f func(*http.Request) (*url.URL, error)) { proxyFromEnvironment = f }(proxyFromEnvironment)
+	proxy, _ := url.Parse("http://proxy.corp:3128")
+	proxyFromEnvironment = func(req *http.Request) (*url.URL, error) {
+		if strings.HasSuffix(req.URL.Hostname(), ".corp") {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+	defer os.Setenv("ALL_PROXY", os.Getenv("ALL_PROXY"))
+	os.Setenv("ALL_PROXY", "socks5://socks.corp:1080")
+
+	setDirRemote("/work/public", "https://example.com/repo")
+	setDirRemote("/work/private", "https://git.corp/repo")
+	setDirRemote("/work/ssh", "ssh://git@example.com/repo")
+
+	if env := runEnv("/work/ssh"); env != nil {
+		t.Errorf("runEnv for ssh remote = %q, want nil", env)
+	}
+	env := strings.Join(runEnv("/work/public"), "\n")
+	if !strings.Contains(env, "https_proxy=http://proxy.corp:3128") || strings.Contains(env, "ALL_PROXY=") {
+		t.Errorf("runEnv for proxied remote = %q, want https_proxy and no ALL_PROXY", env)
+	}
+	env = strings.Join(runEnv("/work/private"), "\n")
+	if strings.Contains(env, "https_proxy=") || !strings.Contains(env, "ALL_PROXY=socks5://socks.corp:1080") {
+		t.Errorf("runEnv for direct remote = %q, want ALL_PROXY and no https_proxy", env)
+	}
+}
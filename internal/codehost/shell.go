@@ -20,7 +20,7 @@ import (
 	"strings"
 	"time"
 
-	"cmd/go/internal/modfetch/codehost"
+	"golang.org/x/vgo/internal/codehost"
 )
 
 func usage() {
@@ -18,8 +18,8 @@ import (
 	"sync"
 	"time"
 
-	"cmd/go/internal/par"
-	"cmd/go/internal/str"
+	"golang.org/x/vgo/internal/par"
+	"golang.org/x/vgo/internal/str"
 )
 
 func NewRepo(vcs, remote string) (Repo, error) {
@@ -75,6 +75,7 @@ func newVCSRepo(vcs, remote string) (Repo, error) {
 	if err != nil {
 		return nil, err
 	}
+	setDirRemote(dir, remote)
 	r.dir = dir
 	if _, err := os.Stat(filepath.Join(dir, "."+vcs)); err != nil {
 		if _, err := Run(dir, cmd.init(r.remote)); err != nil {
@@ -310,6 +311,10 @@ func (r *vcsRepo) Latest() (*RevInfo, error) {
 	return r.Stat("latest")
 }
 
+func (r *vcsRepo) StatAtTime(branch string, t time.Time) (*RevInfo, error) {
+	return nil, fmt.Errorf("%s: looking up revisions by time is not supported", r.cmd.vcs)
+}
+
 func (r *vcsRepo) ReadFile(rev, file string, maxSize int64) ([]byte, error) {
 	if rev == "latest" {
 		rev = r.cmd.latest
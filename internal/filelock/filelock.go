@@ -0,0 +1,32 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package filelock provides advisory file locks, used to coordinate
+// access to the module cache and version control work directories
+// among concurrent processes.
+package filelock
+
+import "os"
+
+// Lock acquires an exclusive advisory lock on the named file,
+// creating the file if necessary and blocking until the lock is held.
+// Calling the returned unlock function releases the lock;
+// exiting the process releases it too.
+//
+// Locks are advisory: they only exclude other callers of Lock.
+// On systems without file locking, Lock only opens the file.
+func Lock(name string) (unlock func(), err error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "lock", Path: name, Err: err}
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
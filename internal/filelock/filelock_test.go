@@ -0,0 +1,53 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filelock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd", "windows":
+		// ok
+	default:
+		t.Skipf("no file locking on %s", runtime.GOOS)
+	}
+
+	dir, err := ioutil.TempDir("", "filelock-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "x.lock")
+
+	unlock, err := Lock(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan bool)
+	go func() {
+		unlock2, err := Lock(name)
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlock2()
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("second Lock succeeded while first lock held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ratelimit limits the rate of requests to each of a set of hosts,
+// so that a command fetching many modules from one host, such as github.com,
+// does not trip the host's abuse detection.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Limiter limits the rate of requests to each host, as set by a
+// specification parsed by Parse. A nil *Limiter limits nothing.
+// It is safe for simultaneous use by multiple goroutines.
+type Limiter struct {
+	limits []limit // in order; the default, if any, last
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// A limit is the rate limit for the hosts matching pattern.
+type limit struct {
+	pattern string  // host pattern, in the syntax of path.Match; "" for any host
+	rate    float64 // requests per second
+	burst   int     // requests allowed at once
+}
+
+// A bucket is the token bucket for one host.
+type bucket struct {
+	tokens float64   // requests allowed now; negative if waiting requests are owed
+	last   time.Time // time tokens was computed
+}
+
+// Parse parses a rate limit specification, a comma-separated list
+// of limits of the form [host=]rate[/burst]. The rate is the number
+// of requests per second, which may be fractional, as in 0.5, and
+// the burst is the number of requests allowed at once, 1 by default.
+// The host may be a pattern in the syntax of path.Match, as in
+// *.example.com. A limit without a host applies to the hosts that
+// no other limit matches, and otherwise the first matching limit
+// applies. Each host has its own allowance, even when a pattern
+// matches several. Parse returns nil for an empty specification.
+func Parse(spec string) (*Limiter, error) {
+	var l Limiter
+	var def []limit
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		lim := limit{burst: 1}
+		r := f
+		if i := strings.Index(f, "="); i >= 0 {
+			lim.pattern, r = strings.ToLower(strings.TrimSpace(f[:i])), f[i+1:]
+			if _, err := path.Match(lim.pattern, ""); err != nil || lim.pattern == "" {
+				return nil, fmt.Errorf("invalid host pattern in %q", f)
+			}
+		}
+		if i := strings.Index(r, "/"); i >= 0 {
+			b, err := strconv.Atoi(strings.TrimSpace(r[i+1:]))
+			if err != nil || b < 1 {
+				return nil, fmt.Errorf("invalid burst in %q: must be a positive integer", f)
+			}
+			r, lim.burst = r[:i], b
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil || !(rate > 0) || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("invalid rate in %q: must be a positive number", f)
+		}
+		lim.rate = rate
+		if lim.pattern == "" {
+			if def != nil {
+				return nil, fmt.Errorf("more than one limit without a host")
+			}
+			def = append(def, lim)
+			continue
+		}
+		l.limits = append(l.limits, lim)
+	}
+	l.limits = append(l.limits, def...)
+	if len(l.limits) == 0 {
+		return nil, nil
+	}
+	l.buckets = make(map[string]*bucket)
+	return &l, nil
+}
+
+// Wait waits until a request to host is allowed.
+func (l *Limiter) Wait(host string) {
+	if d := l.reserve(host, time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// reserve reserves a request to host at time now and returns
+// how long the request must wait before it is allowed.
+func (l *Limiter) reserve(host string, now time.Time) time.Duration {
+	if l == nil || host == "" {
+		return 0
+	}
+	host = strings.ToLower(host)
+	lim, ok := l.limit(host)
+	if !ok {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buckets[host]
+	if b == nil {
+		b = &bucket{tokens: float64(lim.burst), last: now}
+		l.buckets[host] = b
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(float64(lim.burst), b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / lim.rate * float64(time.Second))
+}
+
+// limit returns the limit that applies to host, in lower case.
+func (l *Limiter) limit(host string) (limit, bool) {
+	for _, lim := range l.limits {
+		if lim.pattern == "" {
+			return lim, true
+		}
+		if ok, _ := path.Match(lim.pattern, host); ok {
+			return lim, true
+		}
+	}
+	return limit{}, false
+}
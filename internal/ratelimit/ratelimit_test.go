@@ -0,0 +1,69 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"", " , "} {
+		if l, err := Parse(spec); l != nil || err != nil {
+			t.Errorf("Parse(%q) = %v, %v, want nil, nil", spec, l, err)
+		}
+	}
+	for _, spec := range []string{"x", "0", "-1", "1/0", "1/x", "=1", "[=1", "1,2", "github.com=1/1.5"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	l, err := Parse("GitHub.com=2/3, *.example.com=0.5, 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Unix(1e9, 0)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	for i, tt := range []struct {
+		host string
+		now  time.Time
+		wait time.Duration
+	}{
+		// github.com: burst of 3, then one every 500ms.
+		{"github.com", at(0), 0},
+		{"github.com", at(0), 0},
+		{"GITHUB.COM", at(0), 0},
+		{"github.com", at(0), 500 * time.Millisecond},
+		{"github.com", at(0), 1000 * time.Millisecond},
+		{"github.com", at(1500 * time.Millisecond), 0},
+		{"github.com", at(1500 * time.Millisecond), 500 * time.Millisecond},
+
+		// Each host matching a pattern has its own allowance.
+		{"a.example.com", at(0), 0},
+		{"a.example.com", at(0), 2 * time.Second},
+		{"b.example.com", at(0), 0},
+
+		// Other hosts get the default.
+		{"golang.org", at(0), 0},
+		{"golang.org", at(0), 100 * time.Millisecond},
+		{"", at(0), 0},
+	} {
+		if wait := l.reserve(tt.host, tt.now); wait != tt.wait {
+			t.Errorf("#%d: reserve(%q) = %v, want %v", i, tt.host, wait, tt.wait)
+		}
+	}
+
+	var nl *Limiter
+	if wait := nl.reserve("github.com", t0); wait != 0 {
+		t.Errorf("nil Limiter: reserve = %v, want 0", wait)
+	}
+	l, _ = Parse("github.com=1")
+	if wait := l.reserve("golang.org", t0); wait != 0 {
+		t.Errorf("unmatched host: reserve = %v, want 0", wait)
+	}
+}
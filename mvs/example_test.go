@@ -0,0 +1,124 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mvs_test
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/vgo/mvs"
+)
+
+// graph is a requirement graph held in memory, with single-digit versions.
+type graph map[mvs.Version][]mvs.Version
+
+func (g graph) Required(m mvs.Version) ([]mvs.Version, error) {
+	list, ok := g[m]
+	if !ok {
+		return nil, &mvs.MissingModuleError{Module: m}
+	}
+	return list, nil
+}
+
+func (g graph) Max(v1, v2 string) string {
+	if v1 == "none" || v2 == "" {
+		return v2
+	}
+	if v2 == "none" || v1 == "" || v1 > v2 {
+		return v1
+	}
+	return v2
+}
+
+func (g graph) Upgrade(m mvs.Version) (mvs.Version, error) {
+	for k := range g {
+		if k.Path == m.Path && g.Max(m.Version, k.Version) != m.Version {
+			m = k
+		}
+	}
+	return m, nil
+}
+
+func (g graph) Previous(m mvs.Version) (mvs.Version, error) {
+	p := mvs.Version{Path: m.Path, Version: "none"}
+	for k := range g {
+		if k.Path == m.Path && k.Version < m.Version && g.Max(p.Version, k.Version) != p.Version {
+			p = k
+		}
+	}
+	return p, nil
+}
+
+func v(s string) mvs.Version {
+	i := strings.Index(s, "@")
+	if i < 0 {
+		return mvs.Version{Path: s}
+	}
+	return mvs.Version{Path: s[:i], Version: s[i+1:]}
+}
+
+// The requirement graph from https://research.swtch.com/vgo-mvs.
+var blog = graph{
+	v("A"):   {v("B@1"), v("C@2")},
+	v("B@1"): {v("D@3")},
+	v("C@1"): {v("D@2")},
+	v("C@2"): {v("D@4")},
+	v("C@3"): {v("D@5")},
+	v("C@4"): {v("G@1")},
+	v("D@2"): {v("E@1")},
+	v("D@3"): {v("E@2")},
+	v("D@4"): {v("E@2"), v("F@1")},
+	v("D@5"): {v("E@2")},
+	v("E@1"): nil,
+	v("E@2"): nil,
+	v("F@1"): nil,
+	v("G@1"): {v("C@4")},
+}
+
+func ExampleBuildList() {
+	list, err := mvs.BuildList(v("A"), blog)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(list)
+	// Output: [A B@1 C@2 D@4 E@2 F@1]
+}
+
+func ExampleUpgrade() {
+	list, err := mvs.Upgrade(v("A"), blog, v("C@4"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(list)
+	// Output: [A B@1 C@4 D@4 E@2 F@1 G@1]
+}
+
+func ExampleUpgradeAll() {
+	list, err := mvs.UpgradeAll(v("A"), blog)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(list)
+	// Output: [A B@1 C@4 D@5 E@2 G@1]
+}
+
+func ExampleReq() {
+	list, err := mvs.Upgrade(v("A"), blog, v("C@4"))
+	if err != nil {
+		panic(err)
+	}
+	req, err := mvs.Req(v("A"), list, blog)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(req)
+	// Output: [B@1 C@4 D@4]
+}
+
+func ExampleBuildList_missing() {
+	_, err := mvs.BuildList(v("A"), graph{v("A"): {v("B@1")}})
+	fmt.Println(err)
+	// Output: missing module: B@1
+}
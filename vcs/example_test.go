@@ -0,0 +1,42 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcs_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/vgo/vcs"
+)
+
+// This example prints the release tags of a repository
+// and the license file at the latest one, sharing vgo's clones.
+func Example() {
+	vcs.CacheDir = filepath.Join(os.Getenv("GOPATH"), "src/mod/cache/vcs")
+	repo, err := vcs.Open("git", "https://github.com/rsc/quote")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tags, err := repo.Tags("v")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, tag := range tags {
+		info, err := repo.Stat(tag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s %s %s\n", tag, info.Short, info.Time.Format("2006-01-02"))
+	}
+	if len(tags) > 0 {
+		data, err := repo.ReadFile(tags[len(tags)-1], "LICENSE", 1<<20)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s", data)
+	}
+}
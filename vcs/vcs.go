@@ -0,0 +1,117 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vcs gives tools other than vgo, such as release dashboards and
+// license checkers, the access to version control repositories that vgo
+// uses to download modules: listing tags, resolving revisions, and reading
+// a file, or a zip file of a directory, at a revision, all without checking
+// out a working copy.
+//
+// Repositories are cloned into a cache directory, where later uses,
+// by this process or others, update them instead of cloning them again.
+// Like vgo, Repo holds a lock on a repository's directory while it changes
+// it, so concurrent processes can share the cache: setting CacheDir to
+// vgo's own, the cache/vcs directory of its module cache, shares vgo's
+// clones as well.
+//
+// Repo's methods are the operations vgo's own downloads depend on,
+// so they will keep their meaning as vgo changes; RevInfo may gain
+// fields as vgo records more about revisions.
+package vcs
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/vgo/internal/codehost"
+	"golang.org/x/vgo/internal/par"
+)
+
+// CacheDir is the directory in which Open keeps local copies of
+// repositories. It must be set before the first call to Open and
+// not changed after that.
+var CacheDir string
+
+var (
+	setWorkRoot sync.Once
+	repoCache   par.Cache // by openKey
+)
+
+type openKey struct {
+	vcs    string
+	remote string
+}
+
+// A Repo is a version control repository.
+// It is safe for simultaneous use by multiple goroutines.
+type Repo struct {
+	repo codehost.Repo
+}
+
+// Open returns the repository at the URL remote, which is managed
+// by the version control system vcs: "git", "hg", "svn", "bzr", or "fossil".
+// Opening the same repository again returns the same Repo.
+func Open(vcs, remote string) (*Repo, error) {
+	if CacheDir == "" {
+		return nil, errors.New("vcs: CacheDir not set")
+	}
+	setWorkRoot.Do(func() { codehost.WorkRoot = CacheDir })
+	type cached struct {
+		repo *Repo
+		err  error
+	}
+	c := repoCache.Do(openKey{vcs, remote}, func() interface{} {
+		r, err := codehost.NewRepo(vcs, remote)
+		if err != nil {
+			return cached{nil, err}
+		}
+		return cached{&Repo{r}, nil}
+	}).(cached)
+	return c.repo, c.err
+}
+
+// A RevInfo describes a revision in a repository.
+type RevInfo struct {
+	Name  string    // complete ID in the repository, such as a commit hash
+	Short string    // shortened ID, as used in pseudo-versions
+	Time  time.Time // commit time
+}
+
+// Tags returns the repository's tags that begin with prefix.
+func (r *Repo) Tags(prefix string) ([]string, error) {
+	return r.repo.Tags(prefix)
+}
+
+// Stat returns information about the revision rev, which can be
+// any name the version control system knows for it: a commit hash,
+// a branch, a tag, and so on.
+func (r *Repo) Stat(rev string) (*RevInfo, error) {
+	info, err := r.repo.Stat(rev)
+	if err != nil {
+		return nil, err
+	}
+	return &RevInfo{Name: info.Name, Short: info.Short, Time: info.Time}, nil
+}
+
+// ReadFile returns the content of file, a slash-separated path
+// relative to the repository root, at revision rev.
+// It fails if the file is larger than maxSize bytes.
+// If the file does not exist at rev, the error
+// satisfies os.IsNotExist.
+func (r *Repo) ReadFile(rev, file string, maxSize int64) ([]byte, error) {
+	return r.repo.ReadFile(rev, file, maxSize)
+}
+
+// ReadZip returns a zip file of the directory subdir, a slash-separated
+// path relative to the repository root ("" for the root itself),
+// at revision rev. It fails if the zip file is larger than maxSize bytes.
+// The files in the zip file are all in one top-level directory, whose
+// name is unspecified, holding the directory actualSubdir, which may be
+// a parent of subdir for version control systems that cannot export
+// a single directory: callers should then ignore the files outside subdir.
+func (r *Repo) ReadZip(rev, subdir string, maxSize int64) (zip io.ReadCloser, actualSubdir string, err error) {
+	return r.repo.ReadZip(rev, subdir, maxSize)
+}
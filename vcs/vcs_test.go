@@ -0,0 +1,46 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestOpenSameRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("skipping because git binary not found")
+	}
+	dir, err := ioutil.TempDir("", "vcs-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	CacheDir = dir
+	defer func() { CacheDir = "" }()
+
+	// Opening a repository only prepares its local clone,
+	// without contacting the remote.
+	r1, err := Open("git", "https://example.com/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := Open("git", "https://example.com/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1 != r2 {
+		t.Errorf("Open returned different Repos for the same repository")
+	}
+	r3, err := Open("git", "https://example.com/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r3 == r1 {
+		t.Errorf("Open returned the same Repo for different repositories")
+	}
+}